@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// maxAgentChunkSize caps how much raw data we base64-encode and send per
+// guest-file-write call, keeping the encoded payload comfortably under the
+// QMP message size limit.
+const maxAgentChunkSize = 48 * 1024
+
+// agentUnreachableMarkers lists the substrings virsh prints on stderr when
+// the qemu-guest-agent channel itself is the problem (agent not installed,
+// not started, or not yet connected), as opposed to sudo/virsh being
+// misconfigured or the domain not existing.
+var agentUnreachableMarkers = []string{
+	"guest agent is not connected",
+	"guest agent is not responding",
+}
+
+// isAgentAvailable reports whether the domain's qemu-guest-agent channel
+// responds to a guest-ping. A genuinely unreachable agent is reported as
+// (false, nil); any other failure (bad sudo/virsh setup, unknown domain,
+// etc.) is propagated as an error, the same way isVMRunning does.
+func isAgentAvailable(vmName string) (bool, error) {
+	_, err := qemuAgentCommand(vmName, `{"execute":"guest-ping"}`)
+	if err != nil {
+		if isAgentUnreachableError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// isAgentUnreachableError reports whether err looks like the guest agent
+// itself is unreachable, as opposed to qemuAgentCommand failing for some
+// other reason (e.g. sudo/virsh misconfiguration or an unknown domain).
+func isAgentUnreachableError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, marker := range agentUnreachableMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// injectFileViaAgent writes localPath into destPath on a running VM over the
+// qemu-guest-agent channel, in chunks of at most maxAgentChunkSize bytes.
+func injectFileViaAgent(vmName, localPath, destPath string) error {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read local file: %v", err)
+	}
+
+	openOut, err := qemuAgentCommand(vmName, guestFileOpenCommand(destPath))
+	if err != nil {
+		return fmt.Errorf("guest-file-open failed: %v", err)
+	}
+
+	var openResult struct {
+		Return int `json:"return"`
+	}
+	if err := json.Unmarshal([]byte(openOut), &openResult); err != nil {
+		return fmt.Errorf("failed to parse guest-file-open response: %v\n%s", err, openOut)
+	}
+	handle := openResult.Return
+
+	defer qemuAgentCommand(vmName, guestFileCloseCommand(handle))
+
+	for _, r := range chunkRanges(len(data), maxAgentChunkSize) {
+		chunk := base64.StdEncoding.EncodeToString(data[r.start:r.end])
+
+		if _, err := qemuAgentCommand(vmName, guestFileWriteCommand(handle, chunk)); err != nil {
+			return fmt.Errorf("guest-file-write failed at offset %d: %v", r.start, err)
+		}
+	}
+
+	return nil
+}
+
+// guestFileOpenCommand builds the guest-file-open QMP command that opens
+// path on the guest for writing, truncating any existing content.
+func guestFileOpenCommand(path string) string {
+	return fmt.Sprintf(`{"execute":"guest-file-open","arguments":{"path":%q,"mode":"w+"}}`, path)
+}
+
+// guestFileWriteCommand builds the guest-file-write QMP command that appends
+// a base64-encoded chunk to the open file handle.
+func guestFileWriteCommand(handle int, base64Chunk string) string {
+	return fmt.Sprintf(`{"execute":"guest-file-write","arguments":{"handle":%d,"buf-b64":%q}}`, handle, base64Chunk)
+}
+
+// guestFileCloseCommand builds the guest-file-close QMP command that closes
+// the open file handle.
+func guestFileCloseCommand(handle int) string {
+	return fmt.Sprintf(`{"execute":"guest-file-close","arguments":{"handle":%d}}`, handle)
+}
+
+// byteRange is a half-open [start, end) slice of a byte buffer.
+type byteRange struct {
+	start, end int
+}
+
+// chunkRanges splits a buffer of the given length into consecutive byteRanges
+// of at most chunkSize bytes each, covering the whole buffer.
+func chunkRanges(length, chunkSize int) []byteRange {
+	var ranges []byteRange
+	for offset := 0; offset < length; offset += chunkSize {
+		end := offset + chunkSize
+		if end > length {
+			end = length
+		}
+		ranges = append(ranges, byteRange{start: offset, end: end})
+	}
+	return ranges
+}
+
+// qemuAgentCommand sends a raw QMP guest agent command to the domain via
+// virsh qemu-agent-command and returns its stdout. On failure the returned
+// error includes virsh's stderr, so callers can distinguish a genuinely
+// unreachable guest agent from a broken sudo/virsh invocation.
+func qemuAgentCommand(vmName, command string) (string, error) {
+	cmd := exec.Command("sudo", "virsh", "qemu-agent-command", vmName, command)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("virsh qemu-agent-command execution error: %v: %s", err, exitErr.Stderr)
+		}
+		return "", fmt.Errorf("virsh qemu-agent-command execution error: %v", err)
+	}
+	return string(output), nil
+}
+
+// isVMRunning reports whether the domain is currently in the "running" state.
+func isVMRunning(vmName string) (bool, error) {
+	cmd := exec.Command("sudo", "virsh", "domstate", vmName)
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("virsh command execution error: %v", err)
+	}
+	return parseDomstate(output) == "running", nil
+}
+
+// parseDomstate extracts the state word from virsh domstate's output,
+// trimming the trailing newline virsh always prints.
+func parseDomstate(output []byte) string {
+	return strings.TrimSpace(string(output))
+}
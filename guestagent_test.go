@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGuestFileOpenCommand(t *testing.T) {
+	got := guestFileOpenCommand("/etc/hostname")
+
+	var parsed struct {
+		Execute   string `json:"execute"`
+		Arguments struct {
+			Path string `json:"path"`
+			Mode string `json:"mode"`
+		} `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("guestFileOpenCommand produced invalid JSON: %v\n%s", err, got)
+	}
+	if parsed.Execute != "guest-file-open" || parsed.Arguments.Path != "/etc/hostname" || parsed.Arguments.Mode != "w+" {
+		t.Errorf("guestFileOpenCommand parsed = %+v", parsed)
+	}
+}
+
+func TestGuestFileWriteCommand(t *testing.T) {
+	got := guestFileWriteCommand(3, "aGVsbG8=")
+
+	var parsed struct {
+		Execute   string `json:"execute"`
+		Arguments struct {
+			Handle int    `json:"handle"`
+			Buf64  string `json:"buf-b64"`
+		} `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("guestFileWriteCommand produced invalid JSON: %v\n%s", err, got)
+	}
+	if parsed.Execute != "guest-file-write" || parsed.Arguments.Handle != 3 || parsed.Arguments.Buf64 != "aGVsbG8=" {
+		t.Errorf("guestFileWriteCommand parsed = %+v", parsed)
+	}
+}
+
+func TestGuestFileCloseCommand(t *testing.T) {
+	got := guestFileCloseCommand(5)
+
+	var parsed struct {
+		Execute   string `json:"execute"`
+		Arguments struct {
+			Handle int `json:"handle"`
+		} `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(got), &parsed); err != nil {
+		t.Fatalf("guestFileCloseCommand produced invalid JSON: %v\n%s", err, got)
+	}
+	if parsed.Execute != "guest-file-close" || parsed.Arguments.Handle != 5 {
+		t.Errorf("guestFileCloseCommand parsed = %+v", parsed)
+	}
+}
+
+func TestChunkRanges(t *testing.T) {
+	tests := []struct {
+		length, chunkSize int
+		want              []byteRange
+	}{
+		{0, 10, nil},
+		{5, 10, []byteRange{{0, 5}}},
+		{10, 10, []byteRange{{0, 10}}},
+		{25, 10, []byteRange{{0, 10}, {10, 20}, {20, 25}}},
+	}
+
+	for _, tt := range tests {
+		got := chunkRanges(tt.length, tt.chunkSize)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("chunkRanges(%d, %d) = %+v, want %+v", tt.length, tt.chunkSize, got, tt.want)
+		}
+	}
+}
+
+func TestParseDomstate(t *testing.T) {
+	tests := []struct {
+		output []byte
+		want   string
+	}{
+		{[]byte("running\n"), "running"},
+		{[]byte("shut off\n"), "shut off"},
+		{[]byte("paused\n"), "paused"},
+	}
+
+	for _, tt := range tests {
+		if got := parseDomstate(tt.output); got != tt.want {
+			t.Errorf("parseDomstate(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestIsAgentUnreachableError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New(`virsh qemu-agent-command execution error: exit status 1: error: Guest agent is not responding: QEMU guest agent is not connected`), true},
+		{errors.New(`virsh qemu-agent-command execution error: exit status 1: error: internal error: unable to execute QEMU agent command: Guest agent is not connected`), true},
+		{errors.New(`virsh qemu-agent-command execution error: exit status 1: error: failed to connect to the hypervisor`), false},
+		{errors.New(`virsh qemu-agent-command execution error: exec: "sudo": executable file not found in $PATH`), false},
+	}
+
+	for _, tt := range tests {
+		if got := isAgentUnreachableError(tt.err); got != tt.want {
+			t.Errorf("isAgentUnreachableError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+
+	"github.com/zinrai/kvm-vm-file-inject/pkg/inject"
 )
 
 func main() {
@@ -15,6 +17,15 @@ func main() {
 	var dirFlag = flag.String("dir", "", "Target directory path on the VM (required)")
 	var stdinFlag = flag.Bool("stdin", false, "Read data from standard input (default if neither -stdin nor -source specified)")
 	var sourceFlag = flag.String("source", "", "Path to local source file to read data from")
+	var manifestFlag = flag.String("manifest", "", "Path to a YAML or JSON manifest describing multiple files to inject in a single guestfish session")
+	var modeFlag = flag.String("mode", "offline", "Injection mode: \"offline\" (default, requires the VM to be shut off) or \"agent\" (uses qemu-guest-agent on a running VM)")
+	var backendFlag = flag.String("backend", "exec", "Backend for offline injection: \"exec\" (default, shells out to virt-copy-in/virsh) or \"guestfs\" (uses the libguestfs Go bindings directly; requires building this binary with -tags guestfs)")
+	var templateFlag = flag.Bool("template", false, "Render the source as a Go text/template before uploading")
+	var varsFileFlag = flag.String("vars-file", "", "Path to a YAML or JSON file of template variables")
+	var varFlags varsFlag
+	flag.Var(&varFlags, "var", "Set a template variable as key=value (may be repeated)")
+	var verifyFlag = flag.Bool("verify", true, "Re-read the placed file through the selected backend (virt-cat for -backend=exec) and compare its SHA-256 hash after injection")
+	var verifyMaxSizeFlag = flag.Int64("verify-max-size", 10*1024*1024, "Skip -verify for files larger than this size in bytes")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] VM_NAME\n\n", os.Args[0])
@@ -26,18 +37,44 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  # Copy from standard input\n")
 		fmt.Fprintf(os.Stderr, "  echo \"Hello\" | %s -file hello.txt -dir /home/user vm-name\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  # Copy from local file\n")
-		fmt.Fprintf(os.Stderr, "  %s -source /path/to/local/file.txt -file file.txt -dir /home/user vm-name\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -source /path/to/local/file.txt -file file.txt -dir /home/user vm-name\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Copy many files in one guestfish session\n")
+		fmt.Fprintf(os.Stderr, "  %s -manifest files.yaml vm-name\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Copy into a running VM via qemu-guest-agent\n")
+		fmt.Fprintf(os.Stderr, "  %s -mode=agent -file hello.txt -dir /home/user vm-name\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Copy using the native libguestfs backend instead of virt-copy-in\n")
+		fmt.Fprintf(os.Stderr, "  %s -backend=guestfs -file hello.txt -dir /home/user vm-name\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Render a per-VM hostname file from a shared template\n")
+		fmt.Fprintf(os.Stderr, "  %s -template -source hostname.tmpl -var env=prod -file hostname -dir /etc vm-name\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Skip the post-injection checksum verification\n")
+		fmt.Fprintf(os.Stderr, "  %s -verify=false -file hello.txt -dir /home/user vm-name\n", os.Args[0])
 	}
 
 	flag.Parse()
 
 	// Check required options
-	if *fileFlag == "" || *dirFlag == "" {
-		fmt.Fprintf(os.Stderr, "Error: -file and -dir options are required\n")
+	if *manifestFlag == "" && (*fileFlag == "" || *dirFlag == "") {
+		fmt.Fprintf(os.Stderr, "Error: -file and -dir options are required (unless -manifest is used)\n")
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if *modeFlag != "offline" && *modeFlag != "agent" {
+		fmt.Fprintf(os.Stderr, "Error: -mode must be \"offline\" or \"agent\"\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *modeFlag == "agent" && *manifestFlag != "" {
+		fmt.Fprintf(os.Stderr, "Error: -manifest is not supported with -mode=agent\n")
+		os.Exit(1)
+	}
+
+	if *manifestFlag != "" && *backendFlag != "exec" {
+		fmt.Fprintf(os.Stderr, "Error: -manifest always uses guestfish directly and does not support -backend=%s\n", *backendFlag)
+		os.Exit(1)
+	}
+
 	// Check if both stdin and source flags are provided
 	if *stdinFlag && *sourceFlag != "" {
 		fmt.Fprintf(os.Stderr, "Error: -stdin and -source cannot be used together\n")
@@ -54,32 +91,72 @@ func main() {
 	}
 	vmName := args[0]
 
-	// Verify that the VM is shut off
-	isShutoff, err := isVMShutoff(vmName)
+	injector, err := inject.New(*backendFlag)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	if !isShutoff {
-		fmt.Fprintf(os.Stderr, "Error: VM '%s' is not shut off. For safety, files can only be placed on VMs that are in shutoff state.\n", vmName)
-		os.Exit(1)
+	var useAgent bool
+	if *modeFlag == "agent" {
+		// Verify that the VM is running and reachable via qemu-guest-agent
+		isRunning, err := isVMRunning(vmName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if !isRunning {
+			fmt.Fprintf(os.Stderr, "Error: VM '%s' is not running. -mode=agent requires a running VM.\n", vmName)
+			os.Exit(1)
+		}
+
+		available, err := isAgentAvailable(vmName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if !available {
+			fmt.Fprintf(os.Stderr, "Error: qemu-guest-agent is not reachable on VM '%s'.\n", vmName)
+			os.Exit(1)
+		}
+		useAgent = true
+	} else {
+		// Verify that the VM is shut off
+		isShutoff, err := injector.IsShutoff(vmName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		if !isShutoff {
+			fmt.Fprintf(os.Stderr, "Error: VM '%s' is not shut off. For safety, files can only be placed on VMs that are in shutoff state.\n", vmName)
+			os.Exit(1)
+		}
 	}
 
-	// Get temporary directory
-	tempDir := os.TempDir()
-	fileName := filepath.Base(*fileFlag)
-	tempFilePath := filepath.Join(tempDir, fileName)
+	// Batch/manifest mode: place every entry in a single guestfish session
+	if *manifestFlag != "" {
+		manifest, err := loadManifest(*manifestFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 
-	// Create temporary file
-	tempFile, err := os.Create(tempFilePath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: Failed to create temporary file: %v\n", err)
-		os.Exit(1)
+		if err := injectManifest(vmName, manifest); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if *verifyFlag {
+			fmt.Fprintf(os.Stderr, "Skipping -verify: not supported with -manifest\n")
+		}
+
+		return
 	}
-	defer os.Remove(tempFilePath) // Remove temporary file when function exits
 
-	// Determine input source and copy data to temporary file
+	// Determine input source and read data into memory
+	var content bytes.Buffer
+
 	if *sourceFlag != "" {
 		// Read from source file
 		fmt.Fprintf(os.Stderr, "Reading data from file: %s\n", *sourceFlag)
@@ -91,7 +168,7 @@ func main() {
 		}
 		defer sourceFile.Close()
 
-		_, err = io.Copy(tempFile, sourceFile)
+		_, err = io.Copy(&content, sourceFile)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Failed to copy data from source file: %v\n", err)
 			os.Exit(1)
@@ -100,58 +177,123 @@ func main() {
 		// Read from standard input (default or when -stdin is specified)
 		fmt.Fprintf(os.Stderr, "Reading data from standard input...\n")
 
-		_, err = io.Copy(tempFile, os.Stdin)
+		_, err = io.Copy(&content, os.Stdin)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Failed to read data from standard input: %v\n", err)
 			os.Exit(1)
 		}
 	}
 
-	err = tempFile.Close()
+	// Render as a Go template when requested
+	if *templateFlag {
+		vars := map[string]interface{}{}
+
+		if *varsFileFlag != "" {
+			fileVars, err := loadVarsFile(*varsFileFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			for k, v := range fileVars {
+				vars[k] = v
+			}
+		}
+		for k, v := range varFlags {
+			vars[k] = v
+		}
+
+		facts, err := gatherDomainFacts(vmName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		rendered, err := renderTemplate(content.Bytes(), vars, facts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		content = *bytes.NewBuffer(rendered)
+	}
+
+	// Get temporary directory
+	tempDir := os.TempDir()
+	fileName := filepath.Base(*fileFlag)
+	tempFilePath := filepath.Join(tempDir, fileName)
+
+	// Create temporary file
+	tempFile, err := os.Create(tempFilePath)
 	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to create temporary file: %v\n", err)
+		os.Exit(1)
+	}
+	defer os.Remove(tempFilePath) // Remove temporary file when function exits
+
+	if _, err := tempFile.Write(content.Bytes()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to write temporary file: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := tempFile.Close(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: Failed to close temporary file: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Set virt-copy-in command options
-	copyArgs := []string{"-d", vmName, tempFilePath, *dirFlag}
+	if useAgent {
+		destPath := filepath.Join(*dirFlag, filepath.Base(*fileFlag))
 
-	fmt.Fprintf(os.Stderr, "Executing command: virt-copy-in %s\n", strings.Join(copyArgs, " "))
+		fmt.Fprintf(os.Stderr, "Injecting file via qemu-guest-agent to %s\n", destPath)
 
-	// Execute virt-copy-in command with sudo
-	cmd := exec.Command("sudo", append([]string{"virt-copy-in"}, copyArgs...)...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "virt-copy-in command execution error: %v\n%s\n", err, output)
+		if err := injectFileViaAgent(vmName, tempFilePath, destPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Successfully copied file %s to directory %s on VM %s\n",
+			*fileFlag, *dirFlag, vmName)
+
+		if *verifyFlag {
+			fmt.Fprintf(os.Stderr, "Skipping -verify: not supported with -mode=agent\n")
+		}
+
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Copying %s to directory %s on VM %s via %s backend\n", tempFilePath, *dirFlag, vmName, *backendFlag)
+
+	if err := injector.CopyIn(context.Background(), vmName, tempFilePath, *dirFlag, inject.CopyOptions{}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
 	fmt.Printf("Successfully copied file %s to directory %s on VM %s\n",
 		*fileFlag, *dirFlag, vmName)
-}
 
-// check if VM is shut off
-func isVMShutoff(vmName string) (bool, error) {
-	cmd := exec.Command("sudo", "virsh", "list", "--state-shutoff", "--name")
-	output, err := cmd.Output()
-	if err != nil {
-		return false, fmt.Errorf("virsh command execution error: %v", err)
-	}
+	if *verifyFlag {
+		info, err := os.Stat(tempFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to stat temporary file for verification: %v\n", err)
+			os.Exit(1)
+		}
 
-	// Get list of VM names from output
-	shutoffVMs := strings.Split(strings.TrimSpace(string(output)), "\n")
+		if info.Size() > *verifyMaxSizeFlag {
+			fmt.Fprintf(os.Stderr, "Skipping -verify: file size %d exceeds -verify-max-size %d\n", info.Size(), *verifyMaxSizeFlag)
+			return
+		}
 
-	// If output is empty, no VMs are shut off
-	if len(shutoffVMs) == 1 && shutoffVMs[0] == "" {
-		return false, nil
-	}
+		expectedHash, err := sha256File(tempFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Fprintf(os.Stderr, "Verifying placed file via %s backend...\n", *backendFlag)
 
-	// Check if specified VM is in the list of shut off VMs
-	for _, vm := range shutoffVMs {
-		if strings.TrimSpace(vm) == vmName {
-			return true, nil
+		if err := verifyInjection(context.Background(), injector, vmName, *dirFlag, fileName, expectedHash); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-	}
 
-	return false, nil
+		fmt.Println("Verification succeeded: placed file matches the source.")
+	}
 }
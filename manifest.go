@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestEntry describes a single file placement to be performed as part
+// of a batch injection.
+type ManifestEntry struct {
+	Source  string `yaml:"source" json:"source"`   // local path to read from (mutually exclusive with Content)
+	Content string `yaml:"content" json:"content"` // inline content (mutually exclusive with Source)
+	Dest    string `yaml:"dest" json:"dest"`       // destination path on the VM (required)
+	Mode    string `yaml:"mode" json:"mode"`       // optional octal mode, e.g. "0644"
+	UID     *int   `yaml:"uid" json:"uid"`         // optional owner uid
+	GID     *int   `yaml:"gid" json:"gid"`         // optional owner gid
+}
+
+// Manifest is the top-level document accepted by -manifest.
+type Manifest struct {
+	Files []ManifestEntry `yaml:"files" json:"files"`
+}
+
+// loadManifest reads and parses a YAML or JSON manifest file, selecting the
+// format based on the file extension (.json, otherwise YAML).
+func loadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %v", path, err)
+	}
+
+	var manifest Manifest
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, &manifest)
+	} else {
+		err = yaml.Unmarshal(data, &manifest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %v", path, err)
+	}
+
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("manifest %s does not define any files", path)
+	}
+
+	for i, entry := range manifest.Files {
+		if entry.Dest == "" {
+			return nil, fmt.Errorf("manifest entry %d is missing a dest path", i)
+		}
+		if entry.Source == "" && entry.Content == "" {
+			return nil, fmt.Errorf("manifest entry %d (%s) needs either source or content", i, entry.Dest)
+		}
+		if entry.Source != "" && entry.Content != "" {
+			return nil, fmt.Errorf("manifest entry %d (%s) cannot set both source and content", i, entry.Dest)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// injectManifest places every entry of the manifest into the target VM
+// using a single guestfish session, so the libguestfs appliance is only
+// booted once regardless of how many files are being placed. This always
+// shells out to guestfish directly and does not go through the pkg/inject
+// backends, so -backend is rejected by the caller when -manifest is set.
+func injectManifest(vmName string, manifest *Manifest) error {
+	stagingDir, err := os.MkdirTemp("", "kvm-vm-file-inject-manifest")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	var script strings.Builder
+	for i, entry := range manifest.Files {
+		localPath := entry.Source
+		if entry.Content != "" {
+			localPath = filepath.Join(stagingDir, fmt.Sprintf("entry-%d", i))
+			if err := os.WriteFile(localPath, []byte(entry.Content), 0644); err != nil {
+				return fmt.Errorf("failed to stage inline content for %s: %v", entry.Dest, err)
+			}
+		}
+
+		destDir := filepath.Dir(entry.Dest)
+		fmt.Fprintf(&script, "mkdir-p %s\n", guestfishQuote(destDir))
+		fmt.Fprintf(&script, "upload %s %s\n", guestfishQuote(localPath), guestfishQuote(entry.Dest))
+
+		if entry.Mode != "" {
+			fmt.Fprintf(&script, "chmod 0%s %s\n", strings.TrimPrefix(entry.Mode, "0"), guestfishQuote(entry.Dest))
+		}
+		if entry.UID != nil || entry.GID != nil {
+			// guestfish's chown treats -1 as "leave this side unchanged", so an
+			// entry that only sets one of uid/gid doesn't reset the other to root.
+			uid, gid := -1, -1
+			if entry.UID != nil {
+				uid = *entry.UID
+			}
+			if entry.GID != nil {
+				gid = *entry.GID
+			}
+			fmt.Fprintf(&script, "chown %d %d %s\n", uid, gid, guestfishQuote(entry.Dest))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Executing guestfish batch session for %d file(s) on VM %s\n", len(manifest.Files), vmName)
+
+	cmd := exec.Command("sudo", "guestfish", "--domain", vmName, "-i")
+	cmd.Stdin = strings.NewReader(script.String())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("guestfish command execution error: %v\n%s", err, output)
+	}
+
+	fmt.Printf("Successfully injected %d file(s) into VM %s\n", len(manifest.Files), vmName)
+	return nil
+}
+
+// guestfishQuote wraps s in double quotes for use as a single argument in a
+// guestfish script, escaping the backslashes and quotes guestfish's own
+// parser treats specially so that spaces or embedded quotes in a path can't
+// split it into extra arguments or additional commands.
+func guestfishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
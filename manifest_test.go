@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadManifestYAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.yaml")
+	contents := "files:\n  - dest: /etc/hostname\n    content: \"myhost\"\n"
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write manifest fixture: %v", err)
+	}
+
+	manifest, err := loadManifest(path)
+	if err != nil {
+		t.Fatalf("loadManifest returned error: %v", err)
+	}
+	if len(manifest.Files) != 1 || manifest.Files[0].Dest != "/etc/hostname" {
+		t.Errorf("loadManifest parsed = %+v", manifest.Files)
+	}
+}
+
+func TestLoadManifestJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+	contents := `{"files":[{"dest":"/etc/hosts","content":"127.0.0.1 localhost"}]}`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write manifest fixture: %v", err)
+	}
+
+	manifest, err := loadManifest(path)
+	if err != nil {
+		t.Fatalf("loadManifest returned error: %v", err)
+	}
+	if len(manifest.Files) != 1 || manifest.Files[0].Dest != "/etc/hosts" {
+		t.Errorf("loadManifest parsed = %+v", manifest.Files)
+	}
+}
+
+func TestLoadManifestRejectsEmpty(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(path, []byte("files: []\n"), 0644); err != nil {
+		t.Fatalf("failed to write manifest fixture: %v", err)
+	}
+
+	if _, err := loadManifest(path); err == nil {
+		t.Error("expected an error for a manifest with no files")
+	}
+}
+
+func TestLoadManifestRejectsMissingDest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(path, []byte("files:\n  - content: \"x\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write manifest fixture: %v", err)
+	}
+
+	if _, err := loadManifest(path); err == nil {
+		t.Error("expected an error for an entry missing dest")
+	}
+}
+
+func TestLoadManifestRejectsSourceAndContentTogether(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad.yaml")
+	contents := "files:\n  - dest: /etc/hostname\n    source: /tmp/x\n    content: \"y\"\n"
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write manifest fixture: %v", err)
+	}
+
+	if _, err := loadManifest(path); err == nil {
+		t.Error("expected an error for an entry setting both source and content")
+	}
+}
+
+func TestLoadManifestRejectsNeitherSourceNorContent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(path, []byte("files:\n  - dest: /etc/hostname\n"), 0644); err != nil {
+		t.Fatalf("failed to write manifest fixture: %v", err)
+	}
+
+	if _, err := loadManifest(path); err == nil {
+		t.Error("expected an error for an entry with neither source nor content")
+	}
+}
+
+func TestGuestfishQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"/etc/hostname", `"/etc/hostname"`},
+		{"/tmp/my file.txt", `"/tmp/my file.txt"`},
+		{`/tmp/say "hi"`, `"/tmp/say \"hi\""`},
+		{`/tmp/back\slash`, `"/tmp/back\\slash"`},
+	}
+
+	for _, tt := range tests {
+		if got := guestfishQuote(tt.in); got != tt.want {
+			t.Errorf("guestfishQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
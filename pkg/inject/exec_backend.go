@@ -0,0 +1,59 @@
+package inject
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ExecBackend implements Injector by shelling out to virsh and
+// virt-copy-in with sudo, exactly as the tool has always done.
+type ExecBackend struct{}
+
+// IsShutoff reports whether the named domain is currently shut off.
+func (b *ExecBackend) IsShutoff(vm string) (bool, error) {
+	cmd := exec.Command("sudo", "virsh", "list", "--state-shutoff", "--name")
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("virsh command execution error: %v", err)
+	}
+
+	shutoffVMs := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(shutoffVMs) == 1 && shutoffVMs[0] == "" {
+		return false, nil
+	}
+
+	for _, candidate := range shutoffVMs {
+		if strings.TrimSpace(candidate) == vm {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// CopyIn places src into dstDir on the named domain via virt-copy-in.
+// opts is not applied: no chmod or chown is run on the placed file.
+func (b *ExecBackend) CopyIn(ctx context.Context, vm, src, dstDir string, opts CopyOptions) error {
+	copyArgs := []string{"-d", vm, src, dstDir}
+
+	cmd := exec.CommandContext(ctx, "sudo", append([]string{"virt-copy-in"}, copyArgs...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("virt-copy-in command execution error: %v\n%s", err, output)
+	}
+
+	return nil
+}
+
+// ReadFile reads path from the named domain's disk image via virt-cat.
+func (b *ExecBackend) ReadFile(ctx context.Context, vm, path string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "sudo", "virt-cat", "-d", vm, path)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("virt-cat command execution error: %v", err)
+	}
+
+	return output, nil
+}
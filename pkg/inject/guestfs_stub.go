@@ -0,0 +1,13 @@
+//go:build !guestfs
+
+package inject
+
+import "fmt"
+
+// newGuestfsBackend is the default-build stand-in for the real
+// guestfs_backend.go constructor. Rebuild with -tags guestfs (and the
+// libguestfs Go bindings available, see guestfs_backend.go) to get the
+// native backend instead of this error.
+func newGuestfsBackend() (Injector, error) {
+	return nil, fmt.Errorf("backend %q requires building with -tags guestfs", "guestfs")
+}
@@ -0,0 +1,44 @@
+// Package inject provides the pluggable backends that place files inside a
+// KVM virtual machine's disk image.
+package inject
+
+import (
+	"context"
+	"fmt"
+)
+
+// CopyOptions carries the optional ownership/permission metadata that a
+// backend should apply to the file after it is placed.
+type CopyOptions struct {
+	Mode string // optional octal mode, e.g. "0644"
+	UID  int    // optional owner uid (0 means "leave unchanged" unless GID is also set)
+	GID  int    // optional owner gid
+}
+
+// Injector places a local file inside a domain's disk image and reports on
+// the domain's shutoff state.
+type Injector interface {
+	// IsShutoff reports whether the named domain is currently shut off.
+	IsShutoff(vm string) (bool, error)
+
+	// CopyIn places the local file at src into dstDir on the named domain.
+	CopyIn(ctx context.Context, vm, src, dstDir string, opts CopyOptions) error
+
+	// ReadFile reads the content of path from the named domain's disk image,
+	// for backends to verify a previous CopyIn without assuming sudo/virt-cat.
+	ReadFile(ctx context.Context, vm, path string) ([]byte, error)
+}
+
+// New constructs the Injector for the named backend. An empty name selects
+// the "exec" backend. The "guestfs" backend is only compiled in when built
+// with -tags guestfs; see guestfs_backend.go and guestfs_stub.go.
+func New(backend string) (Injector, error) {
+	switch backend {
+	case "", "exec":
+		return &ExecBackend{}, nil
+	case "guestfs":
+		return newGuestfsBackend()
+	default:
+		return nil, fmt.Errorf("unknown backend %q (expected \"exec\" or \"guestfs\")", backend)
+	}
+}
@@ -0,0 +1,33 @@
+package inject
+
+import "testing"
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		backend string
+		wantErr bool
+	}{
+		{"", false},
+		{"exec", false},
+		// Without -tags guestfs, newGuestfsBackend is the stub from
+		// guestfs_stub.go and always errors.
+		{"guestfs", true},
+		{"bogus", true},
+	}
+
+	for _, tt := range tests {
+		injector, err := New(tt.backend)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("New(%q) expected an error, got none", tt.backend)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("New(%q) returned error: %v", tt.backend, err)
+		}
+		if injector == nil {
+			t.Errorf("New(%q) returned a nil Injector", tt.backend)
+		}
+	}
+}
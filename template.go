@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// varsFlag collects repeated -var key=value flags into a map.
+type varsFlag map[string]string
+
+func (v *varsFlag) String() string {
+	return ""
+}
+
+func (v *varsFlag) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid -var %q, expected key=value", s)
+	}
+	if *v == nil {
+		*v = varsFlag{}
+	}
+	(*v)[parts[0]] = parts[1]
+	return nil
+}
+
+// DomainFacts holds details about the target domain gathered from virsh,
+// made available to templates under the "Domain" key.
+type DomainFacts struct {
+	Name   string
+	UUID   string
+	Memory string
+	VCPUs  string
+	MACs   []string
+}
+
+// gatherDomainFacts collects facts about vmName via virsh dominfo and
+// domifaddr for use in -template rendering.
+func gatherDomainFacts(vmName string) (*DomainFacts, error) {
+	facts := &DomainFacts{Name: vmName}
+
+	dominfoOut, err := exec.Command("sudo", "virsh", "dominfo", vmName).Output()
+	if err != nil {
+		return nil, fmt.Errorf("virsh dominfo execution error: %v", err)
+	}
+	for _, line := range strings.Split(string(dominfoOut), "\n") {
+		key, value, ok := splitDominfoLine(line)
+		if !ok {
+			continue
+		}
+		switch key {
+		case "UUID":
+			facts.UUID = value
+		case "Max memory":
+			facts.Memory = value
+		case "CPU(s)":
+			facts.VCPUs = value
+		}
+	}
+
+	domifaddrOut, err := exec.Command("sudo", "virsh", "domifaddr", vmName).Output()
+	if err != nil {
+		return nil, fmt.Errorf("virsh domifaddr execution error: %v", err)
+	}
+	facts.MACs = parseDomifaddrMACs(string(domifaddrOut))
+
+	return facts, nil
+}
+
+func splitDominfoLine(line string) (key, value string, ok bool) {
+	idx := strings.Index(line, ":")
+	if idx < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:idx]), strings.TrimSpace(line[idx+1:]), true
+}
+
+// parseDomifaddrMACs extracts the MAC address column from virsh domifaddr's
+// table output.
+func parseDomifaddrMACs(output string) []string {
+	var macs []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.Contains(fields[1], ":") {
+			continue
+		}
+		macs = append(macs, fields[1])
+	}
+	return macs
+}
+
+// loadVarsFile reads additional template variables from a YAML or JSON file,
+// selecting the format based on the file extension (.json, otherwise YAML).
+func loadVarsFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vars file %s: %v", path, err)
+	}
+
+	vars := map[string]interface{}{}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, &vars)
+	} else {
+		err = yaml.Unmarshal(data, &vars)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vars file %s: %v", path, err)
+	}
+
+	return vars, nil
+}
+
+// renderTemplate renders src as a Go text/template. vars is exposed at the
+// template root alongside the gathered domain facts under "Domain", e.g.
+// {{.Domain.Name}} or {{.hostname}}.
+func renderTemplate(src []byte, vars map[string]interface{}, facts *DomainFacts) ([]byte, error) {
+	data := map[string]interface{}{"Domain": facts}
+	for k, v := range vars {
+		data[k] = v
+	}
+
+	tmpl, err := template.New("source").Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
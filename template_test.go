@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	facts := &DomainFacts{Name: "vm1", UUID: "abc-123", MACs: []string{"52:54:00:aa:bb:cc"}}
+	vars := map[string]interface{}{"env": "prod"}
+
+	out, err := renderTemplate([]byte("host={{.Domain.Name}} env={{.env}} mac={{index .Domain.MACs 0}}"), vars, facts)
+	if err != nil {
+		t.Fatalf("renderTemplate returned error: %v", err)
+	}
+
+	want := "host=vm1 env=prod mac=52:54:00:aa:bb:cc"
+	if string(out) != want {
+		t.Errorf("renderTemplate output = %q, want %q", out, want)
+	}
+}
+
+func TestRenderTemplateInvalidSyntax(t *testing.T) {
+	_, err := renderTemplate([]byte("{{.Unclosed"), map[string]interface{}{}, &DomainFacts{})
+	if err == nil {
+		t.Fatal("expected an error for malformed template syntax")
+	}
+}
+
+func TestParseDomifaddrMACs(t *testing.T) {
+	output := ` Name       MAC address          Protocol     Address
+-------------------------------------------------------------------------------
+ vnet0      52:54:00:aa:bb:cc     ipv4         192.168.122.5/24
+
+`
+	macs := parseDomifaddrMACs(output)
+	if len(macs) != 1 || macs[0] != "52:54:00:aa:bb:cc" {
+		t.Errorf("parseDomifaddrMACs = %v, want [52:54:00:aa:bb:cc]", macs)
+	}
+}
+
+func TestParseDomifaddrMACsNoInterfaces(t *testing.T) {
+	macs := parseDomifaddrMACs("")
+	if len(macs) != 0 {
+		t.Errorf("parseDomifaddrMACs(\"\") = %v, want empty", macs)
+	}
+}
+
+func TestVarsFlagSet(t *testing.T) {
+	var v varsFlag
+	if err := v.Set("key=value"); err != nil {
+		t.Fatalf("Set returned error: %v", err)
+	}
+	if v["key"] != "value" {
+		t.Errorf("v[%q] = %q, want %q", "key", v["key"], "value")
+	}
+
+	if err := v.Set("novalue"); err == nil {
+		t.Error("expected an error for a -var flag without '='")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/zinrai/kvm-vm-file-inject/pkg/inject"
+)
+
+// sha256File returns the hex-encoded SHA-256 hash of the file at path.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s for hashing: %v", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %v", path, err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyInjection re-reads the file just placed at dstDir/fileName on vmName
+// through injector and compares its SHA-256 hash against expectedHash. Using
+// the Injector abstraction (rather than shelling out to virt-cat directly)
+// keeps -verify honoring whatever -backend the caller selected.
+func verifyInjection(ctx context.Context, injector inject.Injector, vmName, dstDir, fileName, expectedHash string) error {
+	destPath := filepath.Join(dstDir, fileName)
+
+	content, err := injector.ReadFile(ctx, vmName, destPath)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.Sum256(content)
+	actualHash := hex.EncodeToString(h[:])
+
+	if actualHash != expectedHash {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", destPath, expectedHash, actualHash)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSha256File(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(path, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	hash, err := sha256File(path)
+	if err != nil {
+		t.Fatalf("sha256File returned error: %v", err)
+	}
+
+	const want = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	if hash != want {
+		t.Errorf("sha256File = %q, want %q", hash, want)
+	}
+}
+
+func TestSha256FileMissing(t *testing.T) {
+	if _, err := sha256File(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}